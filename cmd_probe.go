@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// probeResult is the outcome of sending a single mutation at the target,
+// recorded as one entry of the probe subcommand's JSON output.
+type probeResult struct {
+	Mutation  string `json:"mutation"`
+	Transport string `json:"transport"`
+	Sent      bool   `json:"sent"`
+	Error     string `json:"error,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Server    string `json:"server,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// WorksForPinhole is a heuristic: a 2xx response is the closest thing a
+	// generic, unattended probe can observe as evidence the ALG accepted
+	// the REGISTER and would punch a pinhole for its Contact. It is not a
+	// substitute for actually confirming the pinhole with a reachable
+	// listener.
+	WorksForPinhole bool `json:"works_for_pinhole"`
+}
+
+// probeReport is the full JSON document the probe subcommand writes out.
+type probeReport struct {
+	Host           string        `json:"host"`
+	Port           string        `json:"port"`
+	Results        []probeResult `json:"results"`
+	Identification *Fingerprint  `json:"identification,omitempty"`
+}
+
+const probeTimeout = 3 * time.Second
+
+// runProbe sends every mutation in probeMutations at host:port, recording
+// which ones the ALG appears to accept, then tries to identify the vendor
+// from the responses it saw.
+func runProbe(host, port string, fingerprints []Fingerprint, logger *slog.Logger) (*probeReport, error) {
+	report := &probeReport{Host: host, Port: port}
+
+	var server, userAgent string
+
+	for _, m := range probeMutations {
+		logger.Info("sending probe mutation", "mutation", m.Name, "transport", m.Transport)
+
+		result := probeResult{Mutation: m.Name, Transport: m.Transport}
+
+		resp, err := sendMutation(host, port, m)
+		if err != nil {
+			result.Error = err.Error()
+			report.Results = append(report.Results, result)
+			continue
+		}
+
+		result.Sent = true
+		result.Status = resp.StatusCode
+		result.Reason = resp.Reason
+		result.WorksForPinhole = resp.StatusCode >= 200 && resp.StatusCode < 300
+
+		if v, ok := resp.Headers.Get("Server"); ok {
+			result.Server = v
+			if server == "" {
+				server = v
+			}
+		}
+		if v, ok := resp.Headers.Get("User-Agent"); ok {
+			result.UserAgent = v
+			if userAgent == "" {
+				userAgent = v
+			}
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	var workingMutations []string
+	for _, r := range report.Results {
+		if r.WorksForPinhole {
+			workingMutations = append(workingMutations, r.Mutation)
+		}
+	}
+
+	report.Identification = Identify(fingerprints, server, userAgent, workingMutations)
+
+	return report, nil
+}
+
+// sendMutation sends m's REGISTER over its socket transport and parses the
+// first response received within probeTimeout.
+func sendMutation(host, port string, m mutation) (*sip.Response, error) {
+	conn, err := net.Dial(m.Transport, net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	defer conn.Close()
+
+	localAddr, ok := conn.LocalAddr().(*net.TCPAddr)
+	localIP := "0.0.0.0"
+	if ok {
+		localIP = localAddr.IP.String()
+	} else if udpAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		localIP = udpAddr.IP.String()
+	}
+
+	req := m.build(localIP, port, port)
+
+	if err := conn.SetDeadline(time.Now().Add(probeTimeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return nil, fmt.Errorf("write: %w", err)
+	}
+
+	parser := sip.NewParser(bufio.NewReader(conn))
+	msg, err := parser.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	resp, ok := msg.(*sip.Response)
+	if !ok {
+		return nil, fmt.Errorf("expected a response, got a request")
+	}
+
+	return resp, nil
+}
+
+// runProbeCommand implements the "probe" subcommand: slipstream probe
+// -host 1.2.3.4 fingerprints a target SIP ALG using the mutation matrix in
+// probe_mutations.go and the database in fingerprints.yaml.
+func runProbeCommand(args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+
+	var (
+		host            string
+		port            string
+		out             string
+		fingerprintPath string
+		logFormat       string
+		logLevel        string
+	)
+
+	fs.StringVar(&host, "host", "", "the host to probe")
+	fs.StringVar(&port, "port", "5060", "the port to probe")
+	fs.StringVar(&out, "out", "", "file to write the JSON report to; stdout if omitted")
+	fs.StringVar(&fingerprintPath, "fingerprints", "", "path to a fingerprint database YAML file; the one shipped with the binary is used if omitted")
+	fs.StringVar(&logFormat, "log-format", "text", "log output format: text or json")
+	fs.StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if host == "" {
+		return fmt.Errorf("you must specify a host")
+	}
+
+	logger, err := newLogger(logFormat, logLevel)
+	if err != nil {
+		return err
+	}
+
+	fingerprints, err := LoadFingerprints(fingerprintPath)
+	if err != nil {
+		return err
+	}
+
+	report, err := runProbe(host, port, fingerprints, logger)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("encoding report: %w", err)
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+
+	return os.WriteFile(out, buf.Bytes(), 0644)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// ConnectBackHandler handles the connection slipstream dials back to a
+// Contact's host:port once a SIP ALG pinhole has let it through. Handlers
+// own conn for its lifetime and must close it before returning.
+type ConnectBackHandler interface {
+	Handle(conn net.Conn, contact *sip.URI) error
+}
+
+// EchoHandler writes a fixed greeting to the punched connection, proving
+// the pinhole lets inbound traffic reach a host it previously would have
+// dropped. It's slipstream's original connect-back behavior.
+type EchoHandler struct{}
+
+func (EchoHandler) Handle(conn net.Conn, contact *sip.URI) error {
+	defer conn.Close()
+	_, err := conn.Write([]byte("hello from the internet!\n"))
+	return err
+}
+
+// TCPForwardHandler bridges the punched connection to a single configured
+// local address, letting an operator tunnel one fixed TCP service through
+// the pinhole instead of just proving it exists.
+type TCPForwardHandler struct {
+	// Addr is the local "host:port" every punched connection is bridged
+	// to.
+	Addr string
+}
+
+func (h TCPForwardHandler) Handle(conn net.Conn, contact *sip.URI) error {
+	defer conn.Close()
+
+	local, err := net.Dial("tcp", h.Addr)
+	if err != nil {
+		return err
+	}
+	defer local.Close()
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(local, conn)
+		errs <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, local)
+		errs <- err
+	}()
+
+	err = <-errs
+	<-errs
+	return err
+}
+
+// connectBack dials the host:port advertised in a Contact header and hands
+// the resulting connection to h, proving (or exploiting) that the NAT's
+// pinhole now lets inbound traffic reach a host it previously would have
+// dropped. logger is the connection's correlated logger (see
+// ServerTransaction.Logger), so these events carry the same conn_id/call_id
+// attributes as the request that triggered them.
+//
+// When origin is the WS/WSS transport, a short completion notice is also
+// written back down the WebSocket connection the REGISTER arrived on, as a
+// second multiplexed message: that's what lets a client relaying through
+// it observe the result without opening a listening socket of its own.
+// Other transports leave origin untouched so we don't inject extra bytes
+// into a SIP control connection an ALG is still watching.
+func connectBack(contact *sip.URI, origin sip.Transport, h ConnectBackHandler, logger *slog.Logger) {
+	logger.Info("connecting back", "contact", contact.HostPort())
+
+	conn, err := net.Dial("tcp", contact.HostPort())
+	if err != nil {
+		logger.Error("unable to connect to host behind NAT", "error", err)
+		return
+	}
+
+	if err := h.Handle(conn, contact); err != nil {
+		logger.Error("connect-back handler error", "error", err)
+	}
+
+	if ws, ok := origin.(*wsConnTransport); ok {
+		if _, err := ws.Write([]byte("connect-back complete\n")); err != nil {
+			logger.Error("unable to notify relay of connect-back completion", "error", err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "fmt"
+
+// buildConnectBackHandler selects the ConnectBackHandler the server uses
+// for every successful pinhole, from the -mode and -forward flag values.
+func buildConnectBackHandler(mode, forward string) (ConnectBackHandler, error) {
+	switch mode {
+	case "echo":
+		return EchoHandler{}, nil
+	case "forward":
+		if forward == "" {
+			return nil, fmt.Errorf("-forward host:port is required in forward mode")
+		}
+		return TCPForwardHandler{Addr: forward}, nil
+	case "socks5":
+		return SOCKS5Handler{}, nil
+	default:
+		return nil, fmt.Errorf("unknown mode %q, want echo, forward or socks5", mode)
+	}
+}
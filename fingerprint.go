@@ -0,0 +1,127 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed fingerprints.yaml
+var defaultFingerprints []byte
+
+// Fingerprint describes how to recognize a vendor's SIP ALG from the
+// responses it sends back to probe_mutations.go's mutation matrix.
+type Fingerprint struct {
+	Vendor  string `yaml:"vendor"`
+	Product string `yaml:"product"`
+
+	// ServerContains and UserAgentContains match substrings of the
+	// corresponding response header, case-insensitively. Either may be
+	// empty to skip that check.
+	ServerContains    string `yaml:"server_contains"`
+	UserAgentContains string `yaml:"user_agent_contains"`
+
+	// WorksMutations lists the probe mutation Names this ALG is known to
+	// still open a pinhole for.
+	WorksMutations []string `yaml:"works_mutations"`
+}
+
+// fingerprintFile is the top level shape of fingerprints.yaml.
+type fingerprintFile struct {
+	Fingerprints []Fingerprint `yaml:"fingerprints"`
+}
+
+// LoadFingerprints reads the fingerprint database from path, or the
+// database shipped with the binary if path is empty.
+func LoadFingerprints(path string) ([]Fingerprint, error) {
+	data := defaultFingerprints
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading fingerprint database: %w", err)
+		}
+	}
+
+	var f fingerprintFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing fingerprint database: %w", err)
+	}
+
+	return f.Fingerprints, nil
+}
+
+// Identify returns the fingerprint that best matches the observed Server
+// and User-Agent header values and working mutations, or nil if no header
+// matches and no working mutation overlaps any fingerprint. workingMutations is
+// the set of probe mutation Names (probeResult.Mutation where
+// WorksForPinhole is true) observed against the target, and is
+// cross-referenced against each candidate's WorksMutations.
+//
+// A header substring match is the strongest signal, since it's an
+// explicit claim by the target rather than an inference, so it is tried
+// first. When more than one fingerprint's headers match, or none do, the
+// fingerprint whose WorksMutations overlaps workingMutations the most is
+// preferred instead; this also lets a target that strips or spoofs its
+// headers still be identified from its pinhole behavior alone.
+func Identify(fingerprints []Fingerprint, server, userAgent string, workingMutations []string) *Fingerprint {
+	server = strings.ToLower(server)
+	userAgent = strings.ToLower(userAgent)
+
+	working := make(map[string]bool, len(workingMutations))
+	for _, m := range workingMutations {
+		working[m] = true
+	}
+
+	var headerMatches []*Fingerprint
+	for i := range fingerprints {
+		fp := &fingerprints[i]
+
+		if fp.ServerContains != "" && strings.Contains(server, strings.ToLower(fp.ServerContains)) {
+			headerMatches = append(headerMatches, fp)
+			continue
+		}
+		if fp.UserAgentContains != "" && strings.Contains(userAgent, strings.ToLower(fp.UserAgentContains)) {
+			headerMatches = append(headerMatches, fp)
+		}
+	}
+
+	candidates := headerMatches
+	if len(candidates) == 0 {
+		for i := range fingerprints {
+			candidates = append(candidates, &fingerprints[i])
+		}
+	}
+
+	var best *Fingerprint
+	bestScore := -1
+	for _, fp := range candidates {
+		if score := mutationOverlap(fp.WorksMutations, working); score > bestScore {
+			best = fp
+			bestScore = score
+		}
+	}
+
+	if len(headerMatches) == 0 && bestScore <= 0 {
+		// Nothing corroborates this guess: no header matched, and not even
+		// one observed-working mutation lines up with the database. Report
+		// unidentified rather than defaulting to the first fingerprint.
+		return nil
+	}
+
+	return best
+}
+
+// mutationOverlap counts how many of wants are present in got.
+func mutationOverlap(wants []string, got map[string]bool) int {
+	n := 0
+	for _, w := range wants {
+		if got[w] {
+			n++
+		}
+	}
+	return n
+}
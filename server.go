@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// Transport is a network transport slipstream can listen for SIP traffic
+// on. Each implementation accepts connections or datagrams, frames them
+// into SIP messages and feeds them to a Server's transaction layer. Unlike
+// sip.Transport (one per peer/connection), a main-package Transport owns
+// its own listen address, since TCP-based transports can't share a port.
+type Transport interface {
+	// Name returns the transport's lowercase name ("tcp", "udp", "tls"),
+	// used for the -transport flag and logging.
+	Name() string
+
+	// ListenAndServe binds this transport's own address and blocks,
+	// handing every request it receives to s, until an unrecoverable
+	// error occurs.
+	ListenAndServe(s *Server) error
+}
+
+// Server is slipstream's SIP-ALG bypass server: it accepts traffic over one
+// or more Transports, parses it with the sip package, and dispatches
+// requests to whatever handlers have been registered via HandleMethod.
+// Zero value is not usable; construct with NewServer.
+type Server struct {
+	tl *sip.TransactionLayer
+
+	// Logger receives every log line the server and its transports
+	// produce. It defaults to slog.Default(); library users who want
+	// their own handler (to write JSON to a file, ship to an aggregator,
+	// etc.) can replace it after construction.
+	Logger *slog.Logger
+}
+
+// NewServer returns a Server with no method handlers registered and
+// logging to slog.Default().
+func NewServer() *Server {
+	return &Server{
+		tl:     sip.NewTransactionLayer(),
+		Logger: slog.Default(),
+	}
+}
+
+// HandleMethod registers h to run for every request of the given SIP
+// method accepted by this server, regardless of which transport it arrived
+// on. Registering a handler for a method that already has one replaces it.
+func (s *Server) HandleMethod(method string, h sip.RequestHandler) {
+	s.tl.HandleMethod(method, h)
+}
+
+// dispatch logs req's method/call-id/cseq against logger and hands it,
+// received over t, to the transaction layer.
+func (s *Server) dispatch(req *sip.Request, t sip.Transport, logger *slog.Logger) {
+	attrs := []any{"method", req.Method, "call_id", req.CallID()}
+	if cseq := req.CSeq(); cseq != nil {
+		attrs = append(attrs, "cseq", cseq.Seq)
+	}
+	logger.Info("received request", attrs...)
+
+	if _, err := s.tl.ReceiveRequest(req, t, logger); err != nil {
+		logger.Error("unable to start transaction", "error", err)
+	}
+}
+
+// Serve runs every transport in transports on its own listen address,
+// returning the first error any of them produces. It blocks until that
+// happens, so callers that want to run multiple transports concurrently
+// should expect Serve to run until the process is killed.
+func (s *Server) Serve(transports []Transport) error {
+	if len(transports) == 0 {
+		return fmt.Errorf("no transports configured")
+	}
+
+	errs := make(chan error, len(transports))
+	for _, t := range transports {
+		t := t
+		go func() {
+			errs <- fmt.Errorf("%s transport: %w", t.Name(), t.ListenAndServe(s))
+		}()
+	}
+
+	return <-errs
+}
@@ -0,0 +1,27 @@
+package sip
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// magicCookie is prepended to every branch parameter slipstream generates,
+// per RFC 3261 section 8.1.1.7, so RFC 3261-compliant elements recognize
+// the branch as usable for transaction matching.
+const magicCookie = "z9hG4bK"
+
+// GenerateBranch returns a new Via branch parameter value, unique per
+// transaction as required by RFC 3261 section 8.1.1.7.
+func GenerateBranch() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return magicCookie + "-" + hex.EncodeToString(b)
+}
+
+// GenerateTag returns a new From/To tag value, unique enough to satisfy the
+// 32-bit-of-randomness requirement in RFC 3261 section 19.3.
+func GenerateTag() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
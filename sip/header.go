@@ -0,0 +1,254 @@
+package sip
+
+import (
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Well-known header names, normalized to their canonical form. Lookups
+// against a Header map are case-insensitive regardless.
+const (
+	HeaderVia         = "Via"
+	HeaderFrom        = "From"
+	HeaderTo          = "To"
+	HeaderCallID      = "Call-ID"
+	HeaderCSeq        = "CSeq"
+	HeaderContact     = "Contact"
+	HeaderMaxForwards = "Max-Forwards"
+	HeaderContentLen  = "Content-Length"
+	HeaderContentType = "Content-Type"
+	HeaderExpires     = "Expires"
+)
+
+// compactForm maps the single-letter compact header names from RFC 3261
+// section 7.3.3 to their canonical form.
+var compactForm = map[string]string{
+	"v": HeaderVia,
+	"f": HeaderFrom,
+	"t": HeaderTo,
+	"i": HeaderCallID,
+	"m": HeaderContact,
+	"l": HeaderContentLen,
+	"c": HeaderContentType,
+}
+
+// irregular holds headers whose canonical casing textproto's word-capitalize
+// rule gets wrong (e.g. it would produce "Call-Id" and "Cseq").
+var irregular = map[string]string{
+	"call-id": HeaderCallID,
+	"cseq":    HeaderCSeq,
+}
+
+func canonicalHeaderName(name string) string {
+	lower := strings.ToLower(name)
+	if full, ok := compactForm[lower]; ok {
+		return full
+	}
+	if full, ok := irregular[lower]; ok {
+		return full
+	}
+	return textproto.CanonicalMIMEHeaderKey(name)
+}
+
+// HeaderField is a single raw "Name: Value" header as it appeared on the
+// wire, in parse order.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+func (h HeaderField) String() string {
+	return fmt.Sprintf("%s: %s", h.Name, h.Value)
+}
+
+// Headers holds the ordered set of header fields on a Message. It supports
+// both raw access (Get/Add, by arbitrary name) and the typed accessors
+// defined on Message for headers slipstream cares about.
+type Headers struct {
+	fields []HeaderField
+}
+
+// Add appends a header field, preserving wire order.
+func (h *Headers) Add(name, value string) {
+	h.fields = append(h.fields, HeaderField{Name: canonicalHeaderName(name), Value: value})
+}
+
+// Set replaces the first header field matching name with value, or appends
+// it if none exists, preserving wire order for everything else. Unlike Add,
+// it never produces a duplicate header field.
+func (h *Headers) Set(name, value string) {
+	name = canonicalHeaderName(name)
+	for i, f := range h.fields {
+		if f.Name == name {
+			h.fields[i].Value = value
+			return
+		}
+	}
+	h.fields = append(h.fields, HeaderField{Name: name, Value: value})
+}
+
+// Get returns the first header field matching name (case-insensitive,
+// compact-form aware) and whether it was found.
+func (h *Headers) Get(name string) (string, bool) {
+	name = canonicalHeaderName(name)
+	for _, f := range h.fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// GetAll returns every header field value matching name, in wire order.
+func (h *Headers) GetAll(name string) []string {
+	name = canonicalHeaderName(name)
+	var out []string
+	for _, f := range h.fields {
+		if f.Name == name {
+			out = append(out, f.Value)
+		}
+	}
+	return out
+}
+
+// All returns every header field in wire order.
+func (h *Headers) All() []HeaderField {
+	return h.fields
+}
+
+// Via is the first Via header, parsed into its protocol, sent-by and
+// parameters. Returns nil if the message has no Via header.
+type Via struct {
+	Transport string // "TCP", "UDP", "TLS", ...
+	Host      string
+	Port      string
+	Params    Params
+}
+
+func (v *Via) String() string {
+	var sb strings.Builder
+	sb.WriteString("SIP/2.0/")
+	sb.WriteString(v.Transport)
+	sb.WriteByte(' ')
+	sb.WriteString(v.Host)
+	if v.Port != "" {
+		sb.WriteByte(':')
+		sb.WriteString(v.Port)
+	}
+	for _, p := range v.Params {
+		sb.WriteByte(';')
+		sb.WriteString(p.Name)
+		if p.Value != "" {
+			sb.WriteByte('=')
+			sb.WriteString(p.Value)
+		}
+	}
+	return sb.String()
+}
+
+// Branch returns the branch= parameter, which RFC 3261 section 17 uses as
+// the transaction identity when it carries the magic cookie "z9hG4bK".
+func (v *Via) Branch() (string, bool) {
+	return v.Params.Get("branch")
+}
+
+func parseVia(value string) (*Via, error) {
+	sentProto, rest, ok := strings.Cut(value, " ")
+	if !ok {
+		return nil, fmt.Errorf("sip: malformed via %q", value)
+	}
+	protoParts := strings.Split(sentProto, "/")
+	if len(protoParts) != 3 {
+		return nil, fmt.Errorf("sip: malformed via protocol %q", sentProto)
+	}
+	transport := protoParts[2]
+
+	rest = strings.TrimSpace(rest)
+	sentBy := rest
+	var params Params
+	if idx := strings.IndexByte(rest, ';'); idx >= 0 {
+		sentBy = rest[:idx]
+		params = parseParams(rest[idx+1:])
+	}
+
+	host := sentBy
+	port := ""
+	if idx := strings.LastIndexByte(sentBy, ':'); idx >= 0 {
+		host = sentBy[:idx]
+		port = sentBy[idx+1:]
+	}
+
+	return &Via{Transport: strings.ToUpper(transport), Host: host, Port: port, Params: params}, nil
+}
+
+// NameAddr is a display-name + URI pair used by the From, To and Contact
+// headers, e.g. `"Alice" <sip:alice@example.org>;tag=abc`.
+type NameAddr struct {
+	DisplayName string
+	URI         *URI
+	Params      Params
+}
+
+// Tag returns the tag= parameter present on From/To headers.
+func (n *NameAddr) Tag() (string, bool) {
+	return n.Params.Get("tag")
+}
+
+func parseNameAddr(value string) (*NameAddr, error) {
+	value = strings.TrimSpace(value)
+
+	displayName := ""
+	addrPart := value
+	if idx := strings.IndexByte(value, '<'); idx >= 0 {
+		displayName = strings.Trim(strings.TrimSpace(value[:idx]), `"`)
+		end := strings.IndexByte(value[idx:], '>')
+		if end < 0 {
+			return nil, fmt.Errorf("sip: unterminated name-addr %q", value)
+		}
+		addrPart = value[idx+1 : idx+end]
+		rest := value[idx+end+1:]
+		uri, err := ParseURI(addrPart)
+		if err != nil {
+			return nil, err
+		}
+		return &NameAddr{DisplayName: displayName, URI: uri, Params: parseParams(strings.TrimPrefix(strings.TrimSpace(rest), ";"))}, nil
+	}
+
+	// bare addr-spec, optionally followed by ;params
+	main := addrPart
+	var params Params
+	if idx := strings.IndexByte(addrPart, ';'); idx >= 0 {
+		main = addrPart[:idx]
+		params = parseParams(addrPart[idx+1:])
+	}
+	uri, err := ParseURI(main)
+	if err != nil {
+		return nil, err
+	}
+	return &NameAddr{URI: uri, Params: params}, nil
+}
+
+// CSeq is the parsed CSeq header: a sequence number and the method it
+// applies to.
+type CSeq struct {
+	Seq    uint32
+	Method string
+}
+
+func (c *CSeq) String() string {
+	return fmt.Sprintf("%d %s", c.Seq, c.Method)
+}
+
+func parseCSeq(value string) (*CSeq, error) {
+	numStr, method, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok {
+		return nil, fmt.Errorf("sip: malformed CSeq %q", value)
+	}
+	n, err := strconv.ParseUint(numStr, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("sip: malformed CSeq number %q: %w", numStr, err)
+	}
+	return &CSeq{Seq: uint32(n), Method: method}, nil
+}
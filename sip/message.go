@@ -0,0 +1,213 @@
+// Package sip implements enough of RFC 3261 to parse and construct SIP
+// messages and drive client/server transactions over them. It is not a
+// general purpose SIP stack: it covers the start-line, header and
+// transaction machinery slipstream needs to speak to SIP ALGs, modeled
+// loosely on emiago/sipgo's API shape.
+package sip
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Message is the data shared by every SIP Request and Response: a set of
+// headers and an optional body. Request and Response embed it and add
+// their respective start-line fields.
+type Message struct {
+	Headers Headers
+	Body    []byte
+}
+
+// Via returns the topmost Via header, or nil if the message has none.
+func (m *Message) Via() *Via {
+	v, ok := m.Headers.Get(HeaderVia)
+	if !ok {
+		return nil
+	}
+	via, err := parseVia(v)
+	if err != nil {
+		return nil
+	}
+	return via
+}
+
+// Contact returns the parsed Contact header, or nil if absent or
+// unparseable. SIP allows multiple Contact header fields or a
+// comma-separated list in REGISTER responses; callers needing more than the
+// first should use Headers.GetAll(HeaderContact).
+func (m *Message) Contact() *NameAddr {
+	v, ok := m.Headers.Get(HeaderContact)
+	if !ok {
+		return nil
+	}
+	na, err := parseNameAddr(v)
+	if err != nil {
+		return nil
+	}
+	return na
+}
+
+// From returns the parsed From header, or nil if absent or unparseable.
+func (m *Message) From() *NameAddr {
+	v, ok := m.Headers.Get(HeaderFrom)
+	if !ok {
+		return nil
+	}
+	na, err := parseNameAddr(v)
+	if err != nil {
+		return nil
+	}
+	return na
+}
+
+// To returns the parsed To header, or nil if absent or unparseable.
+func (m *Message) To() *NameAddr {
+	v, ok := m.Headers.Get(HeaderTo)
+	if !ok {
+		return nil
+	}
+	na, err := parseNameAddr(v)
+	if err != nil {
+		return nil
+	}
+	return na
+}
+
+// CallID returns the Call-ID header value.
+func (m *Message) CallID() string {
+	v, _ := m.Headers.Get(HeaderCallID)
+	return v
+}
+
+// CSeq returns the parsed CSeq header, or nil if absent or unparseable.
+func (m *Message) CSeq() *CSeq {
+	v, ok := m.Headers.Get(HeaderCSeq)
+	if !ok {
+		return nil
+	}
+	cseq, err := parseCSeq(v)
+	if err != nil {
+		return nil
+	}
+	return cseq
+}
+
+// MaxForwards returns the Max-Forwards header value and whether it was
+// present and well-formed.
+func (m *Message) MaxForwards() (int, bool) {
+	v, ok := m.Headers.Get(HeaderMaxForwards)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// ContentLength returns the Content-Length header value, defaulting to 0
+// when absent, matching RFC 3261 section 20.14.
+func (m *Message) ContentLength() int {
+	v, ok := m.Headers.Get(HeaderContentLen)
+	if !ok {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Request is a SIP request message: a method and Request-URI plus the
+// common Message fields.
+type Request struct {
+	Message
+
+	Method     string
+	RequestURI *URI
+}
+
+// NewRequest builds a Request with the given method and Request-URI and no
+// headers set. Callers add headers via req.Headers.Add before sending.
+func NewRequest(method string, uri *URI) *Request {
+	return &Request{Method: method, RequestURI: uri}
+}
+
+// StartLine renders the request-line, e.g. "REGISTER sip:example.org SIP/2.0".
+func (r *Request) StartLine() string {
+	return fmt.Sprintf("%s %s SIP/2.0", r.Method, r.RequestURI.String())
+}
+
+// Response is a SIP response message: a status code and reason phrase plus
+// the common Message fields.
+type Response struct {
+	Message
+
+	StatusCode int
+	Reason     string
+}
+
+// NewResponse builds a Response with the given status code and reason and
+// no headers set.
+func NewResponse(statusCode int, reason string) *Response {
+	return &Response{StatusCode: statusCode, Reason: reason}
+}
+
+// StartLine renders the status-line, e.g. "SIP/2.0 200 OK".
+func (r *Response) StartLine() string {
+	return fmt.Sprintf("SIP/2.0 %d %s", r.StatusCode, r.Reason)
+}
+
+// IsProvisional reports whether the response is a 1xx.
+func (r *Response) IsProvisional() bool {
+	return r.StatusCode >= 100 && r.StatusCode < 200
+}
+
+// NewResponseFromRequest builds a response to req, copying the Via, From,
+// To, Call-ID and CSeq headers a dialog-less response must echo back per
+// RFC 3261 section 8.2.6.2. Callers still need to add a To tag and any
+// Contact header before sending.
+func NewResponseFromRequest(req *Request, statusCode int, reason string) *Response {
+	resp := NewResponse(statusCode, reason)
+	for _, name := range []string{HeaderVia, HeaderFrom, HeaderTo, HeaderCallID, HeaderCSeq} {
+		for _, v := range req.Headers.GetAll(name) {
+			resp.Headers.Add(name, v)
+		}
+	}
+	return resp
+}
+
+// Marshal renders the request as bytes suitable for writing to a stream or
+// datagram transport.
+func (r *Request) Marshal() []byte {
+	return marshal(r.StartLine(), &r.Message)
+}
+
+// Marshal renders the response as bytes suitable for writing to a stream or
+// datagram transport.
+func (r *Response) Marshal() []byte {
+	return marshal(r.StartLine(), &r.Message)
+}
+
+func marshal(startLine string, m *Message) []byte {
+	var sb strings.Builder
+	sb.WriteString(startLine)
+	sb.WriteString("\r\n")
+	for _, h := range m.Headers.All() {
+		sb.WriteString(h.Name)
+		sb.WriteString(": ")
+		sb.WriteString(h.Value)
+		sb.WriteString("\r\n")
+	}
+	if _, ok := m.Headers.Get(HeaderContentLen); !ok {
+		sb.WriteString(HeaderContentLen)
+		sb.WriteString(": ")
+		sb.WriteString(strconv.Itoa(len(m.Body)))
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("\r\n")
+	return append([]byte(sb.String()), m.Body...)
+}
@@ -0,0 +1,130 @@
+package sip
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxStartLineAndHeaders bounds how much header data ReadMessage will buffer
+// before giving up, so a peer that never sends a blank line can't exhaust
+// memory.
+const maxStartLineAndHeaders = 64 * 1024
+
+// maxBodySize bounds the body ReadMessage will allocate for a claimed
+// Content-Length, so a malicious or broken peer can't make the body
+// allocation itself exhaust memory.
+const maxBodySize = 64 * 1024
+
+// Parser reads SIP messages from a stream, recognizing the start-line,
+// headers and a Content-Length-delimited body per RFC 3261 section 7. It
+// keeps per-connection buffering state, so a new Parser should be created
+// per transport connection (see NewParser).
+type Parser struct {
+	r *bufio.Reader
+}
+
+// NewParser returns a Parser reading from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{r: bufio.NewReader(r)}
+}
+
+// ReadMessage reads one SIP message from the stream, returning either a
+// *Request or a *Response depending on the start-line. It blocks until a
+// full message (headers plus any body indicated by Content-Length) has been
+// read, or an error/EOF occurs.
+func (p *Parser) ReadMessage() (any, error) {
+	lines, err := p.readHeaderBlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("sip: empty message")
+	}
+
+	msg := Message{}
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		msg.Headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	contentLength := msg.ContentLength()
+	if contentLength > maxBodySize {
+		return nil, fmt.Errorf("sip: content-length %d exceeds %d bytes", contentLength, maxBodySize)
+	}
+	if contentLength > 0 {
+		body := make([]byte, contentLength)
+		if _, err := io.ReadFull(p.r, body); err != nil {
+			return nil, fmt.Errorf("sip: reading body: %w", err)
+		}
+		msg.Body = body
+	}
+
+	startLine := lines[0]
+	if strings.HasPrefix(startLine, "SIP/2.0") {
+		return parseStatusLine(startLine, msg)
+	}
+	return parseRequestLine(startLine, msg)
+}
+
+// readHeaderBlock reads lines up to and including the blank line that
+// terminates the header section, accepting both CRLF and bare-LF line
+// endings since some ALG implementations mangle line endings.
+func (p *Parser) readHeaderBlock() ([]string, error) {
+	var lines []string
+	var total int
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && len(lines) == 0 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+
+		total += len(line)
+		if total > maxStartLineAndHeaders {
+			return nil, fmt.Errorf("sip: header block exceeds %d bytes", maxStartLineAndHeaders)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func parseRequestLine(line string, msg Message) (*Request, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("sip: malformed request-line %q", line)
+	}
+	uri, err := ParseURI(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("sip: malformed request-uri: %w", err)
+	}
+	return &Request{Message: msg, Method: parts[0], RequestURI: uri}, nil
+}
+
+func parseStatusLine(line string, msg Message) (*Response, error) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("sip: malformed status-line %q", line)
+	}
+	code, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("sip: malformed status code %q: %w", parts[1], err)
+	}
+	reason := ""
+	if len(parts) == 3 {
+		reason = parts[2]
+	}
+	return &Response{Message: msg, StatusCode: code, Reason: reason}, nil
+}
@@ -0,0 +1,114 @@
+package sip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParserCRLFAndLFFraming(t *testing.T) {
+	crlf := "REGISTER sip:example.org SIP/2.0\r\n" +
+		"Via: SIP/2.0/TCP 1.2.3.4:5060;branch=z9hG4bK1\r\n" +
+		"Call-ID: abc\r\n" +
+		"CSeq: 1 REGISTER\r\n" +
+		"Content-Length: 0\r\n" +
+		"\r\n"
+
+	lf := strings.ReplaceAll(crlf, "\r\n", "\n")
+
+	for name, raw := range map[string]string{"crlf": crlf, "lf": lf} {
+		t.Run(name, func(t *testing.T) {
+			msg, err := NewParser(strings.NewReader(raw)).ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			req, ok := msg.(*Request)
+			if !ok {
+				t.Fatalf("expected *Request, got %T", msg)
+			}
+			if req.Method != "REGISTER" {
+				t.Errorf("Method = %q, want REGISTER", req.Method)
+			}
+			if got, _ := req.Headers.Get(HeaderCallID); got != "abc" {
+				t.Errorf("Call-ID = %q, want abc", got)
+			}
+		})
+	}
+}
+
+func TestParserContentLengthBody(t *testing.T) {
+	raw := "REGISTER sip:example.org SIP/2.0\r\n" +
+		"Call-ID: abc\r\n" +
+		"Content-Length: 5\r\n" +
+		"\r\n" +
+		"hello"
+
+	msg, err := NewParser(strings.NewReader(raw)).ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	req := msg.(*Request)
+	if string(req.Body) != "hello" {
+		t.Errorf("Body = %q, want %q", req.Body, "hello")
+	}
+}
+
+func TestParserRejectsOversizedContentLength(t *testing.T) {
+	raw := "REGISTER sip:example.org SIP/2.0\r\n" +
+		"Call-ID: abc\r\n" +
+		"Content-Length: 999999999\r\n" +
+		"\r\n"
+
+	_, err := NewParser(strings.NewReader(raw)).ReadMessage()
+	if err == nil {
+		t.Fatal("expected an error for an oversized Content-Length, got nil")
+	}
+}
+
+func TestParserNegativeContentLengthIgnored(t *testing.T) {
+	raw := "REGISTER sip:example.org SIP/2.0\r\n" +
+		"Call-ID: abc\r\n" +
+		"Content-Length: -1\r\n" +
+		"\r\n"
+
+	msg, err := NewParser(strings.NewReader(raw)).ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	req := msg.(*Request)
+	if len(req.Body) != 0 {
+		t.Errorf("Body = %q, want empty", req.Body)
+	}
+}
+
+func TestParserResponse(t *testing.T) {
+	raw := "SIP/2.0 200 OK\r\n" +
+		"Call-ID: abc\r\n" +
+		"Content-Length: 0\r\n" +
+		"\r\n"
+
+	msg, err := NewParser(strings.NewReader(raw)).ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	resp, ok := msg.(*Response)
+	if !ok {
+		t.Fatalf("expected *Response, got %T", msg)
+	}
+	if resp.StatusCode != 200 || resp.Reason != "OK" {
+		t.Errorf("got %d %q, want 200 OK", resp.StatusCode, resp.Reason)
+	}
+}
+
+func TestParserHeaderBlockTooLarge(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("REGISTER sip:example.org SIP/2.0\r\n")
+	for sb.Len() < maxStartLineAndHeaders+1 {
+		sb.WriteString("X-Pad: " + strings.Repeat("a", 1024) + "\r\n")
+	}
+	sb.WriteString("\r\n")
+
+	_, err := NewParser(strings.NewReader(sb.String())).ReadMessage()
+	if err == nil {
+		t.Fatal("expected an error for an oversized header block, got nil")
+	}
+}
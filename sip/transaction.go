@@ -0,0 +1,336 @@
+package sip
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Timer values from RFC 3261 section 17.1.1.1. T1 is the estimated
+// round-trip time; T2 is the maximum retransmission interval for
+// non-INVITE requests and INVITE responses; T4 is the time a message stays
+// in the network.
+const (
+	T1 = 500 * time.Millisecond
+	T2 = 4 * time.Second
+	T4 = 5 * time.Second
+)
+
+// ServerTxState is the state of a server transaction, merging the non-INVITE
+// (17.2.2) and INVITE (17.2.1) state machines. The INVITE-only Confirmed
+// state is defined but no transaction enters it.
+type ServerTxState int
+
+const (
+	ServerTxTrying ServerTxState = iota
+	ServerTxProceeding
+	ServerTxCompleted
+	ServerTxConfirmed
+	ServerTxTerminated
+)
+
+func (s ServerTxState) String() string {
+	switch s {
+	case ServerTxTrying:
+		return "Trying"
+	case ServerTxProceeding:
+		return "Proceeding"
+	case ServerTxCompleted:
+		return "Completed"
+	case ServerTxConfirmed:
+		return "Confirmed"
+	case ServerTxTerminated:
+		return "Terminated"
+	default:
+		return "Unknown"
+	}
+}
+
+// ServerTransaction implements the server transaction state machines of
+// RFC 3261 section 17.2. It is created for every incoming request and
+// handles retransmitting the last final response when the request is
+// retransmitted, and garbage collecting itself after Timer J/I expires.
+type ServerTransaction struct {
+	mu        sync.Mutex
+	branch    string
+	method    string
+	request   *Request
+	transport Transport
+	state     ServerTxState
+	logger    *slog.Logger
+
+	lastResponse []byte
+	onTerminate  func(*ServerTransaction)
+
+	timer *time.Timer
+}
+
+func newServerTransaction(req *Request, branch string, t Transport, logger *slog.Logger, onTerminate func(*ServerTransaction)) *ServerTransaction {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ServerTransaction{
+		branch:      branch,
+		method:      req.Method,
+		request:     req,
+		transport:   t,
+		state:       ServerTxTrying,
+		logger:      logger,
+		onTerminate: onTerminate,
+	}
+}
+
+// Respond sends resp over the transaction, advancing its state machine.
+// Provisional (1xx) responses move a transaction to Proceeding; final
+// responses (>=200) move it to Completed and arm a Timer J-length
+// termination timer so retransmissions of the request get the cached
+// final response instead of re-invoking the handler. INVITE and non-INVITE
+// transactions are treated alike: Timers G/H are not implemented, so a
+// final INVITE response is not retransmitted on its own.
+func (tx *ServerTransaction) Respond(resp *Response) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	raw := resp.Marshal()
+	if _, err := tx.transport.Write(raw); err != nil {
+		return err
+	}
+
+	if resp.IsProvisional() {
+		tx.state = ServerTxProceeding
+		return nil
+	}
+
+	tx.lastResponse = raw
+	tx.state = ServerTxCompleted
+
+	// RFC 3261 17.2.2: non-INVITE transactions sit in Completed for
+	// Timer J (64*T1 on unreliable transports, 0 on reliable ones) so a
+	// retransmitted request can be answered from cache.
+	wait := 64 * T1
+	if tx.transport.Reliable() {
+		wait = 0
+	}
+	tx.armTimer(wait)
+	return nil
+}
+
+// ReceiveRequest is called when a retransmission of the original request
+// arrives. In Proceeding or Completed it replays the last response sent (or
+// does nothing in Proceeding, per 17.2.1/17.2.2, since no final response
+// exists yet); in Trying it is dropped since the handler is already
+// running.
+func (tx *ServerTransaction) ReceiveRequest() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.state == ServerTxCompleted && tx.lastResponse != nil {
+		tx.transport.Write(tx.lastResponse)
+	}
+}
+
+// armTimer schedules the transaction's termination after d, which may be
+// zero (reliable transports skip Timer J/K entirely per RFC 3261 17.1.4/
+// 17.2.2). It is always called with tx.mu held, so the termination itself
+// must happen on its own goroutine via time.AfterFunc rather than inline:
+// firing synchronously here would re-enter Lock from within the caller's
+// critical section and deadlock.
+func (tx *ServerTransaction) armTimer(d time.Duration) {
+	if tx.timer != nil {
+		tx.timer.Stop()
+	}
+	if d < 0 {
+		d = 0
+	}
+	tx.timer = time.AfterFunc(d, func() {
+		tx.mu.Lock()
+		tx.state = ServerTxTerminated
+		tx.mu.Unlock()
+		if tx.onTerminate != nil {
+			tx.onTerminate(tx)
+		}
+	})
+}
+
+// State returns the transaction's current state.
+func (tx *ServerTransaction) State() ServerTxState {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.state
+}
+
+// Transport returns the Transport the originating request arrived on, so
+// handlers that need to write something other than a SIP response back to
+// the same peer (e.g. multiplexing extra data over a WebSocket connection)
+// can do so.
+func (tx *ServerTransaction) Transport() Transport {
+	return tx.transport
+}
+
+// Logger returns the logger associated with the connection this
+// transaction's request arrived on, so handlers can log with the same
+// correlation attributes (e.g. conn_id) the transport layer used.
+func (tx *ServerTransaction) Logger() *slog.Logger {
+	return tx.logger
+}
+
+// ClientTxState is the state of a client transaction per RFC 3261 section
+// 17.1.
+type ClientTxState int
+
+const (
+	ClientTxCalling ClientTxState = iota
+	ClientTxProceeding
+	ClientTxCompleted
+	ClientTxTerminated
+)
+
+// ClientTransaction implements the non-INVITE client transaction state
+// machine of RFC 3261 section 17.1.2: it retransmits the request on an
+// exponential backoff (Timer E) until a response arrives or Timer F times
+// the transaction out, and holds the transaction open for Timer K on
+// unreliable transports to absorb duplicate responses.
+type ClientTransaction struct {
+	mu        sync.Mutex
+	branch    string
+	request   *Request
+	transport Transport
+	state     ClientTxState
+
+	responses chan *Response
+	done      chan struct{}
+	closeDone sync.Once
+
+	timerE    *time.Timer
+	timerF    *time.Timer
+	timerK    *time.Timer
+	curTimerE time.Duration
+}
+
+func newClientTransaction(req *Request, branch string, t Transport) *ClientTransaction {
+	return &ClientTransaction{
+		branch:    branch,
+		request:   req,
+		transport: t,
+		state:     ClientTxCalling,
+		responses: make(chan *Response, 1),
+		done:      make(chan struct{}),
+	}
+}
+
+// Send transmits the request and starts the retransmission/timeout timers.
+func (tx *ClientTransaction) Send() error {
+	if _, err := tx.transport.Write(tx.request.Marshal()); err != nil {
+		return err
+	}
+
+	tx.timerF = time.AfterFunc(64*T1, tx.timeout)
+
+	if !tx.transport.Reliable() {
+		tx.curTimerE = T1
+		tx.timerE = time.AfterFunc(tx.curTimerE, tx.retransmit)
+	}
+	return nil
+}
+
+func (tx *ClientTransaction) retransmit() {
+	tx.mu.Lock()
+	if tx.state != ClientTxCalling && tx.state != ClientTxProceeding {
+		tx.mu.Unlock()
+		return
+	}
+	tx.transport.Write(tx.request.Marshal())
+	tx.curTimerE *= 2
+	if tx.curTimerE > T2 {
+		tx.curTimerE = T2
+	}
+	tx.timerE = time.AfterFunc(tx.curTimerE, tx.retransmit)
+	tx.mu.Unlock()
+}
+
+func (tx *ClientTransaction) timeout() {
+	tx.mu.Lock()
+	tx.state = ClientTxTerminated
+	tx.mu.Unlock()
+	tx.stopTimers()
+	// Timer F and Timer K can each decide to terminate the transaction;
+	// time.Timer.Stop does not wait out a callback already running, so
+	// both can reach here concurrently. closeDone keeps that from
+	// closing tx.done twice.
+	tx.closeDone.Do(func() { close(tx.done) })
+}
+
+func (tx *ClientTransaction) stopTimers() {
+	if tx.timerE != nil {
+		tx.timerE.Stop()
+	}
+	if tx.timerF != nil {
+		tx.timerF.Stop()
+	}
+	if tx.timerK != nil {
+		tx.timerK.Stop()
+	}
+}
+
+// Receive delivers a matched response to the transaction, waking up
+// Responses().
+func (tx *ClientTransaction) Receive(resp *Response) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.state == ClientTxTerminated {
+		return
+	}
+
+	if resp.IsProvisional() {
+		tx.state = ClientTxProceeding
+		select {
+		case tx.responses <- resp:
+		default:
+		}
+		return
+	}
+
+	tx.state = ClientTxCompleted
+	if tx.timerE != nil {
+		tx.timerE.Stop()
+	}
+	if tx.timerF != nil {
+		tx.timerF.Stop()
+	}
+
+	select {
+	case tx.responses <- resp:
+	default:
+	}
+
+	wait := T4
+	if tx.transport.Reliable() {
+		wait = 0
+	}
+	tx.timerK = time.AfterFunc(wait, func() {
+		tx.mu.Lock()
+		tx.state = ClientTxTerminated
+		tx.mu.Unlock()
+		tx.closeDone.Do(func() { close(tx.done) })
+	})
+}
+
+// Responses returns a channel that receives every response delivered to
+// the transaction (provisional and final).
+func (tx *ClientTransaction) Responses() <-chan *Response {
+	return tx.responses
+}
+
+// Done returns a channel that is closed once the transaction has fully
+// terminated (Timer F timeout or Timer K expiry after a final response).
+func (tx *ClientTransaction) Done() <-chan struct{} {
+	return tx.done
+}
+
+// State returns the transaction's current state.
+func (tx *ClientTransaction) State() ClientTxState {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.state
+}
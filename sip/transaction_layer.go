@@ -0,0 +1,147 @@
+package sip
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// RequestHandler processes a request delivered to a newly created server
+// transaction. Implementations respond by calling tx.Respond; slipstream's
+// HandleMethod dispatch wires these up per SIP method.
+type RequestHandler func(req *Request, tx *ServerTransaction)
+
+// TransactionLayer matches incoming requests and responses to transactions
+// per the branch-matching rules of RFC 3261 section 17.2.3, creating a new
+// ServerTransaction for each request that does not match one in progress.
+// It is transport-agnostic: callers feed it parsed messages and a Transport
+// to write responses/retransmissions back out on.
+type TransactionLayer struct {
+	mu       sync.Mutex
+	serverTx map[string]*ServerTransaction
+	clientTx map[string]*ClientTransaction
+
+	handlers map[string]RequestHandler
+}
+
+// NewTransactionLayer returns an empty TransactionLayer.
+func NewTransactionLayer() *TransactionLayer {
+	return &TransactionLayer{
+		serverTx: make(map[string]*ServerTransaction),
+		clientTx: make(map[string]*ClientTransaction),
+		handlers: make(map[string]RequestHandler),
+	}
+}
+
+// HandleMethod registers h as the handler for requests of the given
+// method (e.g. "REGISTER", "OPTIONS"). Registering a handler for a method
+// that already has one replaces it.
+func (tl *TransactionLayer) HandleMethod(method string, h RequestHandler) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.handlers[method] = h
+}
+
+// serverKey matches RFC 3261 17.2.3: branch, sent-by host/port and method
+// (CANCEL is matched against its INVITE's transaction by everything but
+// method, but slipstream does not originate INVITEs that get cancelled, so
+// method is included unconditionally here).
+func serverKey(branch, sentBy, method string) string {
+	return branch + "|" + sentBy + "|" + method
+}
+
+// ReceiveRequest routes an incoming request to its matching server
+// transaction, creating one and invoking the registered handler if none
+// exists yet. t is used to write responses for this transaction; logger is
+// attached to the transaction so the handler can log with the same
+// correlation attributes (e.g. conn_id) the caller used.
+func (tl *TransactionLayer) ReceiveRequest(req *Request, t Transport, logger *slog.Logger) (*ServerTransaction, error) {
+	via := req.Via()
+	if via == nil {
+		return nil, fmt.Errorf("sip: request missing Via header")
+	}
+	branch, ok := via.Branch()
+	if !ok {
+		return nil, fmt.Errorf("sip: request Via missing branch parameter")
+	}
+
+	key := serverKey(branch, via.Host+":"+via.Port, req.Method)
+
+	tl.mu.Lock()
+	if tx, exists := tl.serverTx[key]; exists {
+		tl.mu.Unlock()
+		tx.ReceiveRequest()
+		return tx, nil
+	}
+
+	tx := newServerTransaction(req, branch, t, logger, tl.onServerTxTerminate(key))
+	tl.serverTx[key] = tx
+	handler := tl.handlers[req.Method]
+	tl.mu.Unlock()
+
+	if handler != nil {
+		go handler(req, tx)
+	}
+	return tx, nil
+}
+
+func (tl *TransactionLayer) onServerTxTerminate(key string) func(*ServerTransaction) {
+	return func(*ServerTransaction) {
+		tl.mu.Lock()
+		delete(tl.serverTx, key)
+		tl.mu.Unlock()
+	}
+}
+
+// NewClientTransaction creates and registers a client transaction for req,
+// which must already carry a Via header with a branch parameter (see
+// GenerateBranch). Callers should call Send on the returned transaction.
+func (tl *TransactionLayer) NewClientTransaction(req *Request, t Transport) (*ClientTransaction, error) {
+	via := req.Via()
+	if via == nil {
+		return nil, fmt.Errorf("sip: request missing Via header")
+	}
+	branch, ok := via.Branch()
+	if !ok {
+		return nil, fmt.Errorf("sip: request Via missing branch parameter")
+	}
+
+	tx := newClientTransaction(req, branch, t)
+
+	tl.mu.Lock()
+	tl.clientTx[branch] = tx
+	tl.mu.Unlock()
+
+	go func() {
+		<-tx.Done()
+		tl.mu.Lock()
+		delete(tl.clientTx, branch)
+		tl.mu.Unlock()
+	}()
+
+	return tx, nil
+}
+
+// ReceiveResponse routes an incoming response to its matching client
+// transaction, returning false if no transaction matches (e.g. it already
+// terminated).
+func (tl *TransactionLayer) ReceiveResponse(resp *Response) bool {
+	via := resp.Via()
+	if via == nil {
+		return false
+	}
+	branch, ok := via.Branch()
+	if !ok {
+		return false
+	}
+
+	tl.mu.Lock()
+	tx, exists := tl.clientTx[branch]
+	tl.mu.Unlock()
+	if !exists {
+		return false
+	}
+
+	tx.Receive(resp)
+	return true
+}
@@ -0,0 +1,137 @@
+package sip
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport is a minimal sip.Transport that records what was written,
+// for use by the transaction tests below.
+type fakeTransport struct {
+	mu       sync.Mutex
+	writes   [][]byte
+	reliable bool
+}
+
+func (f *fakeTransport) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cp := append([]byte(nil), b...)
+	f.writes = append(f.writes, cp)
+	return len(b), nil
+}
+
+func (f *fakeTransport) Reliable() bool { return f.reliable }
+
+func (f *fakeTransport) writeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.writes)
+}
+
+func newTestRequest(branch string) *Request {
+	req := NewRequest("REGISTER", &URI{Scheme: "sip", Host: "example.org"})
+	req.Headers.Add(HeaderVia, "SIP/2.0/TCP 1.2.3.4:5060;branch="+branch)
+	req.Headers.Add(HeaderCallID, "abc")
+	req.Headers.Add(HeaderCSeq, "1 REGISTER")
+	return req
+}
+
+// TestServerTransactionRespondReliableDoesNotDeadlock guards against the
+// regression where armTimer fired its zero-delay termination callback
+// inline while Respond still held tx.mu, deadlocking every final response
+// sent over a reliable transport (tcp/tls/ws).
+func TestServerTransactionRespondReliableDoesNotDeadlock(t *testing.T) {
+	tl := NewTransactionLayer()
+	req := newTestRequest(GenerateBranch())
+	ft := &fakeTransport{reliable: true}
+
+	tx, err := tl.ReceiveRequest(req, ft, nil)
+	if err != nil {
+		t.Fatalf("ReceiveRequest: %v", err)
+	}
+
+	resp := NewResponseFromRequest(req, 200, "OK")
+
+	done := make(chan error, 1)
+	go func() { done <- tx.Respond(resp) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Respond: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Respond did not return; deadlocked")
+	}
+
+	// State() also takes tx.mu; if the deadlock regressed, this hangs too.
+	deadline := time.Now().Add(time.Second)
+	for tx.State() != ServerTxTerminated {
+		if time.Now().After(deadline) {
+			t.Fatalf("transaction never terminated, state = %v", tx.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServerTransactionReplaysLastResponseOnRetransmit(t *testing.T) {
+	tl := NewTransactionLayer()
+	req := newTestRequest(GenerateBranch())
+	ft := &fakeTransport{reliable: false}
+
+	tx, err := tl.ReceiveRequest(req, ft, nil)
+	if err != nil {
+		t.Fatalf("ReceiveRequest: %v", err)
+	}
+
+	resp := NewResponseFromRequest(req, 200, "OK")
+	if err := tx.Respond(resp); err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if got := ft.writeCount(); got != 1 {
+		t.Fatalf("writeCount after Respond = %d, want 1", got)
+	}
+
+	// Simulate the same request arriving again (e.g. the client never saw
+	// the response): the cached final response should be replayed as-is,
+	// not re-run through the handler.
+	tx.ReceiveRequest()
+	if got := ft.writeCount(); got != 2 {
+		t.Fatalf("writeCount after retransmit = %d, want 2", got)
+	}
+}
+
+func TestClientTransactionDoneClosesOnce(t *testing.T) {
+	req := newTestRequest(GenerateBranch())
+	ft := &fakeTransport{reliable: true}
+	tx := newClientTransaction(req, GenerateBranch(), ft)
+
+	if err := tx.Send(); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	resp := NewResponseFromRequest(req, 200, "OK")
+
+	// Timer K (armed by Receive, wait=0 on a reliable transport) and
+	// Timer F (armed by Send) both terminate the transaction; racing them
+	// against each other must not panic on a double close(tx.done).
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		tx.Receive(resp)
+	}()
+	go func() {
+		defer wg.Done()
+		tx.timeout()
+	}()
+	wg.Wait()
+
+	select {
+	case <-tx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done channel never closed")
+	}
+}
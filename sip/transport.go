@@ -0,0 +1,16 @@
+package sip
+
+// Transport abstracts the network connection a transaction writes requests
+// and responses to. It exists so the transaction layer below does not need
+// to know whether it is sitting on top of TCP, UDP or TLS.
+type Transport interface {
+	// Write sends one full SIP message to the peer the transaction is
+	// talking to.
+	Write(b []byte) (int, error)
+
+	// Reliable reports whether the underlying transport guarantees
+	// in-order delivery. Per RFC 3261 sections 17.1.1.2 and 17.2.1,
+	// reliable transports never arm the retransmission timers (A/E for
+	// client transactions, G for INVITE server transactions).
+	Reliable() bool
+}
@@ -0,0 +1,137 @@
+package sip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// URI represents a SIP or SIPS URI as defined in RFC 3261 section 19.1.
+//
+//	sip:user@host:port;uri-parameters
+type URI struct {
+	Scheme string // "sip" or "sips"
+	User   string
+	Host   string
+	Port   string // empty if not present
+
+	// Params holds the uri-parameters (e.g. transport, rinstance) in the
+	// order they appeared.
+	Params Params
+}
+
+// ParseURI parses a raw SIP URI, e.g. "sip:wuzzi@10.0.0.5:5060;transport=TCP".
+func ParseURI(raw string) (*URI, error) {
+	raw = strings.TrimSpace(raw)
+	raw = strings.Trim(raw, "<>")
+
+	scheme, rest, ok := strings.Cut(raw, ":")
+	if !ok {
+		return nil, fmt.Errorf("sip: missing scheme in uri %q", raw)
+	}
+	scheme = strings.ToLower(scheme)
+	if scheme != "sip" && scheme != "sips" {
+		return nil, fmt.Errorf("sip: unsupported uri scheme %q", scheme)
+	}
+
+	hostport := rest
+	var params Params
+	if idx := strings.IndexByte(rest, ';'); idx >= 0 {
+		hostport = rest[:idx]
+		params = parseParams(rest[idx+1:])
+	}
+
+	user := ""
+	if idx := strings.LastIndexByte(hostport, '@'); idx >= 0 {
+		user = hostport[:idx]
+		hostport = hostport[idx+1:]
+	}
+
+	host := hostport
+	port := ""
+	if idx := strings.LastIndexByte(hostport, ':'); idx >= 0 {
+		host = hostport[:idx]
+		port = hostport[idx+1:]
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("sip: missing host in uri %q", raw)
+	}
+
+	return &URI{
+		Scheme: scheme,
+		User:   user,
+		Host:   host,
+		Port:   port,
+		Params: params,
+	}, nil
+}
+
+// HostPort returns "host:port", falling back to the default SIP port when
+// Port is unset.
+func (u *URI) HostPort() string {
+	if u.Port == "" {
+		return fmt.Sprintf("%s:%s", u.Host, DefaultPort)
+	}
+	return fmt.Sprintf("%s:%s", u.Host, u.Port)
+}
+
+func (u *URI) String() string {
+	var sb strings.Builder
+	sb.WriteString(u.Scheme)
+	sb.WriteByte(':')
+	if u.User != "" {
+		sb.WriteString(u.User)
+		sb.WriteByte('@')
+	}
+	sb.WriteString(u.Host)
+	if u.Port != "" {
+		sb.WriteByte(':')
+		sb.WriteString(u.Port)
+	}
+	for _, p := range u.Params {
+		sb.WriteByte(';')
+		sb.WriteString(p.Name)
+		if p.Value != "" {
+			sb.WriteByte('=')
+			sb.WriteString(p.Value)
+		}
+	}
+	return sb.String()
+}
+
+// DefaultPort is the default port for the sip: scheme when a URI omits one.
+const DefaultPort = "5060"
+
+// Param is a single name[=value] uri- or header-parameter.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Params is an ordered list of parameters, preserving the order they were
+// parsed in so re-serialized messages stay close to the original wire form.
+type Params []Param
+
+// Get returns the value of the named parameter and whether it was present.
+func (p Params) Get(name string) (string, bool) {
+	for _, param := range p {
+		if strings.EqualFold(param.Name, name) {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+func parseParams(raw string) Params {
+	if raw == "" {
+		return nil
+	}
+
+	parts := strings.Split(raw, ";")
+	params := make(Params, 0, len(parts))
+	for _, part := range parts {
+		name, value, _ := strings.Cut(part, "=")
+		params = append(params, Param{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+	return params
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// SOCKS5 constants from RFC 1928. Only the subset slipstream's server-side
+// implementation needs is defined here.
+const (
+	socks5Version = 0x05
+
+	socks5AuthNone           = 0x00
+	socks5AuthNoneAcceptable = 0xff
+
+	socks5CmdConnect = 0x01
+
+	socks5AtypIPv4   = 0x01
+	socks5AtypDomain = 0x03
+	socks5AtypIPv6   = 0x04
+
+	socks5ReplySuccess         = 0x00
+	socks5ReplyGeneralError    = 0x01
+	socks5ReplyCmdNotSupported = 0x07
+)
+
+// SOCKS5Handler runs a full SOCKS5 server (RFC 1928) over the punched
+// connection, letting an operator tunnel arbitrary TCP through the NAT'd
+// host instead of just proving the pinhole exists.
+type SOCKS5Handler struct{}
+
+func (SOCKS5Handler) Handle(conn net.Conn, contact *sip.URI) error {
+	defer conn.Close()
+
+	if err := socks5Handshake(conn); err != nil {
+		return fmt.Errorf("socks5 handshake: %w", err)
+	}
+
+	target, err := socks5ReadRequest(conn)
+	if err != nil {
+		return fmt.Errorf("socks5 request: %w", err)
+	}
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		socks5WriteReply(conn, socks5ReplyGeneralError)
+		return fmt.Errorf("dialing socks5 target %s: %w", target, err)
+	}
+	defer upstream.Close()
+
+	if err := socks5WriteReply(conn, socks5ReplySuccess); err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(upstream, conn)
+		errs <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, upstream)
+		errs <- err
+	}()
+
+	err = <-errs
+	<-errs
+	return err
+}
+
+// socks5Handshake reads the client's method-selection message and replies
+// that no authentication is required, the only method slipstream supports.
+func socks5Handshake(conn net.Conn) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("unsupported socks version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return err
+	}
+
+	for _, m := range methods {
+		if m == socks5AuthNone {
+			_, err := conn.Write([]byte{socks5Version, socks5AuthNone})
+			return err
+		}
+	}
+
+	conn.Write([]byte{socks5Version, socks5AuthNoneAcceptable})
+	return fmt.Errorf("client offered no acceptable auth methods")
+}
+
+// socks5ReadRequest reads a CONNECT request and returns its target as a
+// "host:port" string.
+func socks5ReadRequest(conn net.Conn) (string, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported socks version %d", header[0])
+	}
+	if header[1] != socks5CmdConnect {
+		socks5WriteReply(conn, socks5ReplyCmdNotSupported)
+		return "", fmt.Errorf("unsupported socks command %d", header[1])
+	}
+
+	var host string
+	switch header[3] {
+	case socks5AtypIPv4:
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socks5AtypIPv6:
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		host = net.IP(addr).String()
+	case socks5AtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", fmt.Errorf("unsupported socks address type %d", header[3])
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return "", err
+	}
+	port := binary.BigEndian.Uint16(portBytes)
+
+	return fmt.Sprintf("%s:%d", host, port), nil
+}
+
+// socks5WriteReply writes a reply with BND.ADDR/BND.PORT left zeroed,
+// which is fine for a client that only cares whether the CONNECT
+// succeeded.
+func socks5WriteReply(conn net.Conn, reply byte) error {
+	_, err := conn.Write([]byte{socks5Version, reply, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
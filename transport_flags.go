@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultTransportPort is each transport's listen port when -transport
+// doesn't override it with a "name:port" entry. tcp/udp/ws share
+// localPort (the -lp flag, matching slipstream's original single-port
+// behavior); tls and wss get the ports routers conventionally run
+// SIP-over-TLS and HTTPS on, since they can't coexist with tcp/ws on
+// localPort.
+func defaultTransportPort(name, localPort string) string {
+	switch name {
+	case "tls":
+		return "5061"
+	case "wss":
+		return "443"
+	default:
+		return localPort
+	}
+}
+
+// buildTransports parses the comma-separated value of -transport into the
+// Transport implementations the server should listen on. Each entry is a
+// transport name, optionally followed by ":port" to override that
+// transport's default listen port (e.g. "tcp,tls:5062").
+func buildTransports(spec, localPort, tlsCertFile, tlsKeyFile, wsPath string) ([]Transport, error) {
+	var transports []Transport
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		name, port, _ := strings.Cut(entry, ":")
+		name = strings.ToLower(name)
+		if port == "" {
+			port = defaultTransportPort(name, localPort)
+		}
+		addr := ":" + port
+
+		switch name {
+		case "tcp":
+			transports = append(transports, TCPTransport{Addr: addr})
+		case "udp":
+			transports = append(transports, UDPTransport{Addr: addr})
+		case "tls":
+			transports = append(transports, TLSTransport{Addr: addr, CertFile: tlsCertFile, KeyFile: tlsKeyFile})
+		case "ws":
+			transports = append(transports, WSTransport{Addr: addr, Path: wsPath})
+		case "wss":
+			transports = append(transports, WSTransport{Addr: addr, Path: wsPath, TLS: true, CertFile: tlsCertFile, KeyFile: tlsKeyFile})
+		default:
+			return nil, fmt.Errorf("unknown transport %q", name)
+		}
+	}
+	return transports, nil
+}
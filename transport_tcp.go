@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// connTransport adapts a net.Conn to sip.Transport so the transaction layer
+// can write responses without knowing about net.Conn directly.
+type connTransport struct {
+	conn     net.Conn
+	reliable bool
+}
+
+func (t *connTransport) Write(b []byte) (int, error) { return t.conn.Write(b) }
+func (t *connTransport) Reliable() bool              { return t.reliable }
+
+// TCPTransport listens for SIP traffic over plain TCP, one connection per
+// peer.
+type TCPTransport struct {
+	// Addr is the "host:port" or ":port" to listen on.
+	Addr string
+}
+
+func (TCPTransport) Name() string { return "tcp" }
+
+func (t TCPTransport) ListenAndServe(s *Server) error {
+	l, err := net.Listen("tcp", t.Addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.Logger.Error("tcp: unable to accept connection", "error", err)
+			continue
+		}
+
+		go serveStreamConn(conn, s, true)
+	}
+}
+
+// serveStreamConn reads every SIP message sent on conn and hands requests
+// off to the server's transaction layer. Shared by the TCP and TLS
+// transports, which differ only in how the net.Conn was obtained.
+func serveStreamConn(conn net.Conn, s *Server, reliable bool) {
+	defer conn.Close()
+
+	logger := s.Logger.With("conn_id", generateConnID(), "remote_addr", conn.RemoteAddr().String())
+	logger.Info("accepted connection")
+
+	t := &connTransport{conn: conn, reliable: reliable}
+	parser := sip.NewParser(conn)
+
+	for {
+		msg, err := parser.ReadMessage()
+		if err != nil {
+			logger.Info("connection closed", "error", err)
+			return
+		}
+
+		req, ok := msg.(*sip.Request)
+		if !ok {
+			logger.Warn("ignoring unexpected response on server connection")
+			continue
+		}
+
+		s.dispatch(req, t, logger)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/tls"
+)
+
+// TLSTransport listens for SIP traffic over TLS, used by the growing
+// number of routers whose SIP ALG only inspects SIP/5061. If CertFile and
+// KeyFile are empty, ListenAndServe generates a self-signed certificate at
+// startup rather than requiring the operator to provide one.
+type TLSTransport struct {
+	// Addr is the "host:port" or ":port" to listen on.
+	Addr     string
+	CertFile string
+	KeyFile  string
+}
+
+func (TLSTransport) Name() string { return "tls" }
+
+func (t TLSTransport) ListenAndServe(s *Server) error {
+	cfg, err := buildTLSConfig(t.CertFile, t.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	l, err := tls.Listen("tcp", t.Addr, cfg)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.Logger.Error("tls: unable to accept connection", "error", err)
+			continue
+		}
+
+		go serveStreamConn(conn, s, true)
+	}
+}
+
+// buildTLSConfig loads certFile/keyFile if both are given, or generates a
+// self-signed certificate otherwise. Shared by the TLS and WSS transports.
+func buildTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+	}
+
+	cert, err := generateSelfSignedCert()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// udpConnTransport adapts a net.PacketConn plus a fixed remote address to
+// sip.Transport, so responses for a given peer go back to the address that
+// peer's requests arrived from.
+type udpConnTransport struct {
+	pc     net.PacketConn
+	remote net.Addr
+}
+
+func (t *udpConnTransport) Write(b []byte) (int, error) { return t.pc.WriteTo(b, t.remote) }
+func (t *udpConnTransport) Reliable() bool              { return false }
+
+// udpPoolMaxIdle bounds how many distinct remote addresses UDPTransport
+// keeps a cached udpConnTransport for, so a long-running listener that
+// hears from many peers (or a spoofed-source flood) doesn't grow the pool
+// without bound. Once the cap is hit, the oldest entry is evicted to make
+// room, the same "make progress, don't fall over" tradeoff as the rest of
+// slipstream's bounds (see maxStartLineAndHeaders/maxBodySize).
+const udpPoolMaxIdle = 4096
+
+// UDPTransport listens for SIP traffic over UDP, the transport most
+// consumer router SIP ALGs actually inspect. Since UDP is connectionless,
+// it keeps a pool of per-remote-addr Transports so every response for a
+// given peer goes back out to the address its requests arrived from.
+type UDPTransport struct {
+	// Addr is the "host:port" or ":port" to listen on.
+	Addr string
+}
+
+func (UDPTransport) Name() string { return "udp" }
+
+func (t UDPTransport) ListenAndServe(s *Server) error {
+	pc, err := net.ListenPacket("udp", t.Addr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	var (
+		mu        sync.Mutex
+		pool      = make(map[string]*udpConnTransport)
+		poolOrder []string
+	)
+
+	buf := make([]byte, 65535)
+	for {
+		n, remote, err := pc.ReadFrom(buf)
+		if err != nil {
+			s.Logger.Error("udp: unable to read packet", "error", err)
+			continue
+		}
+
+		logger := s.Logger.With("remote_addr", remote.String())
+
+		// Each datagram is one complete message; frame it by
+		// Content-Length the same way the stream transports do, rather
+		// than looking for a \r\n\r\n sentinel that a single UDP payload
+		// may not even contain a trailing copy of.
+		parser := sip.NewParser(bytes.NewReader(buf[:n]))
+		msg, err := parser.ReadMessage()
+		if err != nil {
+			logger.Error("udp: unable to parse message", "error", err)
+			continue
+		}
+
+		req, ok := msg.(*sip.Request)
+		if !ok {
+			logger.Warn("udp: ignoring unexpected response")
+			continue
+		}
+
+		key := remote.String()
+		mu.Lock()
+		conn, exists := pool[key]
+		if !exists {
+			if len(poolOrder) >= udpPoolMaxIdle {
+				oldest := poolOrder[0]
+				poolOrder = poolOrder[1:]
+				delete(pool, oldest)
+			}
+			conn = &udpConnTransport{pc: pc, remote: remote}
+			pool[key] = conn
+			poolOrder = append(poolOrder, key)
+		}
+		mu.Unlock()
+
+		s.dispatch(req, conn, logger)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/jrozner/slipstream/sip"
+)
+
+// wsUpgrader upgrades inbound HTTP connections to WebSocket for the WS/WSS
+// transport. It advertises the "sip" subprotocol from RFC 7118 section 4.1
+// but doesn't require a peer to negotiate it, since most ALGs triggering
+// this traffic won't bother.
+var wsUpgrader = websocket.Upgrader{
+	Subprotocols: []string{"sip"},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+// wsConnTransport adapts a *websocket.Conn to sip.Transport, writing each
+// SIP message as a single WebSocket text frame per RFC 7118 section 5.
+type wsConnTransport struct {
+	conn *websocket.Conn
+}
+
+func (t *wsConnTransport) Write(b []byte) (int, error) {
+	if err := t.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (t *wsConnTransport) Reliable() bool { return true }
+
+// WSTransport listens for SIP-over-WebSocket traffic (RFC 7118), letting
+// slipstream reach ALGs sitting behind networks that only permit outbound
+// HTTP/WebSocket egress. With TLS set it serves WSS instead of plain WS,
+// generating a self-signed certificate the same way TLSTransport does when
+// CertFile/KeyFile are empty.
+type WSTransport struct {
+	// Addr is the "host:port" or ":port" to listen on.
+	Addr     string
+	Path     string
+	TLS      bool
+	CertFile string
+	KeyFile  string
+}
+
+func (t WSTransport) Name() string {
+	if t.TLS {
+		return "wss"
+	}
+	return "ws"
+}
+
+func (t WSTransport) ListenAndServe(s *Server) error {
+	path := t.Path
+	if path == "" {
+		path = "/"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			s.Logger.Error("ws: unable to upgrade connection", "error", err)
+			return
+		}
+		go serveWSConn(conn, s)
+	})
+
+	if !t.TLS {
+		return http.ListenAndServe(t.Addr, mux)
+	}
+
+	cfg, err := buildTLSConfig(t.CertFile, t.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	l, err := tls.Listen("tcp", t.Addr, cfg)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	return http.Serve(l, mux)
+}
+
+func serveWSConn(conn *websocket.Conn, s *Server) {
+	defer conn.Close()
+
+	logger := s.Logger.With("conn_id", generateConnID(), "remote_addr", conn.RemoteAddr().String())
+	logger.Info("accepted websocket connection")
+
+	t := &wsConnTransport{conn: conn}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			logger.Info("websocket connection closed", "error", err)
+			return
+		}
+
+		msg, err := sip.NewParser(bytes.NewReader(data)).ReadMessage()
+		if err != nil {
+			logger.Warn("ws: unable to parse message", "error", err)
+			continue
+		}
+
+		req, ok := msg.(*sip.Request)
+		if !ok {
+			logger.Warn("ws: ignoring unexpected response")
+			continue
+		}
+
+		s.dispatch(req, t, logger)
+	}
+}
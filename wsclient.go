@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/jrozner/slipstream/sip"
+)
+
+// runRelayClient sends a REGISTER to relayURL over SIP-over-WebSocket
+// (RFC 7118) instead of dialing the target directly, and then waits on
+// that same connection for the connect-back proof message a successful ALG
+// pinhole produces. Unlike sendRequest+setupListener, it never opens a
+// listening socket of its own, so it still works through egress that only
+// permits outbound WebSocket to 443, and it lets the "local" side of the
+// pinhole be a peer reachable only through the relay rather than this
+// host.
+func runRelayClient(relayURL, localIP, localPort, remotePort string, logger *slog.Logger) error {
+	conn, _, err := websocket.DefaultDialer.Dial(relayURL, nil)
+	if err != nil {
+		return fmt.Errorf("dialing relay: %w", err)
+	}
+	defer conn.Close()
+
+	uri, err := sip.ParseURI("sip:example.org;transport=WS")
+	if err != nil {
+		return err
+	}
+
+	req := sip.NewRequest("REGISTER", uri)
+	branch := sip.GenerateBranch()
+	req.Headers.Add(sip.HeaderVia, fmt.Sprintf("SIP/2.0/WS %s:%s;branch=%s;rport", localIP, remotePort, branch))
+	req.Headers.Add(sip.HeaderMaxForwards, "70")
+	req.Headers.Add(sip.HeaderContact, fmt.Sprintf("<sip:wuzzi@%s:%s;transport=WS>", localIP, localPort))
+	req.Headers.Add(sip.HeaderTo, "<sip:wuzzi@example.org;transport=WS>")
+	req.Headers.Add(sip.HeaderFrom, "<sip:wuzzi@example.org;transport=WS>;tag="+sip.GenerateTag())
+	req.Headers.Add(sip.HeaderCallID, sip.GenerateBranch())
+	req.Headers.Add(sip.HeaderCSeq, "1 REGISTER")
+	req.Headers.Add(sip.HeaderExpires, "60")
+
+	logger.Info("sending register over relay", "relay", relayURL, "call_id", req.CallID())
+
+	// Send the REGISTER through a client transaction (Timer F terminates
+	// the transaction if no SIP response ever arrives; the read loop below
+	// does not stop on it) rather than writing it directly; Timer E
+	// retransmission never fires since
+	// wsConnTransport reports Reliable() true, matching RFC 3261 17.1.2.
+	tl := sip.NewTransactionLayer()
+	tx, err := tl.NewClientTransaction(req, &wsConnTransport{conn: conn})
+	if err != nil {
+		return err
+	}
+	if err := tx.Send(); err != nil {
+		return fmt.Errorf("sending register: %w", err)
+	}
+
+	// The relay multiplexes two kinds of message down this one socket: SIP
+	// responses, and the connect-back proof forwarded from whatever dialed
+	// the Contact above. SIP responses are fed to the transaction layer so
+	// Timer F/K behave correctly; we only return on the latter.
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("reading from relay: %w", err)
+		}
+
+		if resp, err := sip.NewParser(bytes.NewReader(data)).ReadMessage(); err == nil {
+			if resp, ok := resp.(*sip.Response); ok {
+				tl.ReceiveResponse(resp)
+			}
+			logger.Info("received sip response over relay")
+			continue
+		}
+
+		fmt.Printf("received message from remote server: `%s`\n", string(bytes.TrimRight(data, "\n")))
+		return nil
+	}
+}